@@ -0,0 +1,457 @@
+package cmds
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"text/template"
+
+	"github.com/appscodelabs/brewer/internal/git"
+	"github.com/spf13/cobra"
+)
+
+func NewCmdBuild() *cobra.Command {
+	var brew Homebrew
+
+	cmd := &cobra.Command{
+		Use:   "build",
+		Short: "Build a homebrew formula and its artifact manifest",
+
+		Run: func(cmd *cobra.Command, args []string) {
+			runBuild(brew)
+		},
+	}
+
+	cmd.Flags().StringVar(&brew.Owner, "owner", "", "Current repo owner")
+	cmd.Flags().StringVar(&brew.Repo, "repo", "", "Current repo name")
+	cmd.Flags().StringVar(&brew.Folder, "folder", "", "Folder inside the tap repository to put the formula. Not applied: publish always writes the formula to the tap repository's root folder.")
+	cmd.Flags().StringVar(&brew.Caveats, "caveats", "", "Caveats for the user of your binary. Default is empty")
+	cmd.Flags().StringVar(&brew.Homepage, "homepage", "https://appscode.com", "Your app's homepage.")
+	cmd.Flags().StringVar(&brew.Description, "description", "", "Your app's description. Default is empty")
+	cmd.Flags().StringArrayVar(&brew.Dependencies, "dependencies", []string{}, "Packages your package depends on.")
+	cmd.Flags().StringArrayVar(&brew.Conflicts, "conflicts", []string{}, "Packages that conflict with your package")
+	cmd.Flags().StringArrayVar(&brew.Artifacts, "artifact", []string{}, "Per-platform archive, as os/arch=path (eg linux/arm/v7=dist/tool-linux-armv7). Repeatable; may be used for darwin/amd64, darwin/arm64, linux/amd64, linux/arm64 and linux/arm/{v5,v6,v7}.")
+	cmd.Flags().StringVar(&brew.Provider, "provider", "github", "Git provider the formula's release URL is resolved for: github, gitlab, or gitea.")
+	cmd.Flags().StringVar(&brew.APIURL, "api-url", "", "Base API URL for a self-hosted GitLab/Gitea instance. Defaults to the provider's public SaaS API.")
+	cmd.Flags().StringVar(&brew.TemplateFile, "template-file", "", "Path to a custom formula template, overriding the built-in one.")
+	cmd.Flags().StringVar(&brew.CustomBlock, "custom-block", "", "Raw Ruby injected above the formula class, eg require lines.")
+	cmd.Flags().StringVar(&brew.Test, "test", "", "Body of the formula's `test do ... end` block.")
+	cmd.Flags().StringVar(&brew.PostInstall, "post-install", "", "Body of the formula's `def post_install ... end` block.")
+	cmd.Flags().StringVar(&brew.Service, "service", "", "Body of the formula's `service do ... end` block, Homebrew's replacement for `plist`.")
+	cmd.Flags().StringVar(&brew.DownloadStrategy, "download-strategy", "", "Ruby download strategy class for the `url` line's `using:` option, eg CurlDownloadStrategy.")
+
+	return cmd
+}
+
+type Homebrew struct {
+	Name         string
+	Owner        string
+	Repo         string
+	BrewOwner    string
+	BrewRepo     string
+	Author       string
+	AuthorEmail  string
+	Folder       string
+	Caveats      string
+	Plist        string
+	Install      string
+	Dependencies []string
+	Test         string
+	Conflicts    []string
+	Description  string
+	Homepage     string
+	Artifacts    []string
+	Tag          string
+
+	CommitMessageTemplate string
+	PullRequest           bool
+	Provider              string
+	APIURL                string
+
+	TemplateFile     string
+	CustomBlock      string
+	PostInstall      string
+	Service          string
+	DownloadStrategy string
+}
+
+// runBuild computes every artifact's SHA256, renders the formula, and
+// writes both it and a manifest describing what was built to dist/, ready
+// for `publish` to pick up without re-hashing the artifacts; `publish` only
+// re-hashes the formula file to check it against the manifest.
+func runBuild(brew Homebrew) {
+	brew.Name = brew.Repo
+
+	tag, err := git.Clean(git.Run("tag", "-l", "--points-at", "HEAD"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	brew.Tag = tag
+
+	urlTemplate, err := releaseURLTemplate(brew.Provider, brew.APIURL)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	artifacts, err := artifactsFor(brew, tag, urlTemplate)
+	if err != nil {
+		log.Fatal(err)
+	}
+	brew.Install = installFor(brew.Name, artifacts)
+
+	tmplText := formulaTemplate
+	if brew.TemplateFile != "" {
+		raw, err := ioutil.ReadFile(brew.TemplateFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		tmplText = string(raw)
+	}
+
+	content, err := buildFormula(brew, artifacts, tmplText)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	formulaPath := filepath.Join("dist/", brew.Name+".rb")
+	if err := ioutil.WriteFile(formulaPath, content.Bytes(), 0644); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := validateRuby(formulaPath); err != nil {
+		log.Fatal(err)
+	}
+
+	formulaSum, err := calculateBytes(content.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	manifest := Manifest{
+		Name:  brew.Name,
+		Owner: brew.Owner,
+		Repo:  brew.Repo,
+		Tag:   tag,
+	}
+	for _, a := range artifacts {
+		manifest.Entries = append(manifest.Entries, ManifestEntry{
+			Type:   UploadableBinary,
+			OS:     a.OS,
+			Arch:   a.Arch,
+			Path:   a.Path,
+			SHA256: a.SHA256,
+		})
+	}
+	manifest.Entries = append(manifest.Entries, ManifestEntry{
+		Type:   UploadableBrewTap,
+		Path:   formulaPath,
+		SHA256: formulaSum,
+	})
+
+	manifestBytes, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	manifestPath := filepath.Join("dist/", brew.Name+".manifest.json")
+	if err := ioutil.WriteFile(manifestPath, manifestBytes, 0644); err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("wrote %s and %s\n", formulaPath, manifestPath)
+}
+
+func buildFormula(brew Homebrew, artifacts []Artifact, tmplText string) (bytes.Buffer, error) {
+	data, err := dataFor(brew, artifacts)
+	if err != nil {
+		return bytes.Buffer{}, err
+	}
+	return doBuildFormula(data, tmplText)
+}
+
+func doBuildFormula(data templateData, tmplText string) (out bytes.Buffer, err error) {
+	tmpl, err := template.New(data.Name).Parse(tmplText)
+
+	if err != nil {
+		return out, err
+	}
+	err = tmpl.Execute(&out, data)
+	return
+}
+
+// validateRuby shells out to `ruby -c` to catch syntax errors in the
+// rendered formula, if a Ruby interpreter is available on PATH.
+func validateRuby(path string) error {
+	if _, err := exec.LookPath("ruby"); err != nil {
+		return nil
+	}
+	out, err := exec.Command("ruby", "-c", path).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("formula failed ruby -c: %s", out)
+	}
+	return nil
+}
+
+func dataFor(brew Homebrew, artifacts []Artifact) (result templateData, err error) {
+	if len(artifacts) == 0 {
+		return result, fmt.Errorf("no artifacts to build a formula for")
+	}
+
+	first := artifacts[0]
+	result = templateData{
+		Name:             formulaNameFor(brew.Name),
+		DownloadURL:      first.URL,
+		DownloadStrategy: brew.DownloadStrategy,
+		Desc:             brew.Description,
+		Homepage:         brew.Homepage,
+		Owner:            brew.Owner,
+		Repo:             brew.Repo,
+		Tag:              first.Version,
+		Version:          first.Version,
+		Caveats:          split(brew.Caveats),
+		File:             first.Name,
+		SHA256:           first.SHA256,
+		Dependencies:     brew.Dependencies,
+		Conflicts:        brew.Conflicts,
+		Plist:            brew.Plist,
+		Install:          split(brew.Install),
+		CustomBlock:      split(brew.CustomBlock),
+		Test:             split(brew.Test),
+		PostInstall:      split(brew.PostInstall),
+		Service:          split(brew.Service),
+	}
+
+	if len(artifacts) == 1 {
+		return result, nil
+	}
+
+	result.Platforms = platformsFor(artifacts)
+	return result, nil
+}
+
+// platformsFor groups artifacts by OS and renders each group's per-arch
+// Hardware::CPU condition, in the order the artifacts were declared.
+func platformsFor(artifacts []Artifact) []platformBlock {
+	var order []string
+	byOS := map[string][]Artifact{}
+	for _, a := range artifacts {
+		if _, ok := byOS[a.OS]; !ok {
+			order = append(order, a.OS)
+		}
+		byOS[a.OS] = append(byOS[a.OS], a)
+	}
+
+	var blocks []platformBlock
+	for _, os := range order {
+		group := byOS[os]
+		archs := make([]archBlock, 0, len(group))
+		for i, a := range group {
+			keyword := "if"
+			if i > 0 {
+				keyword = "elsif"
+			}
+			archs = append(archs, archBlock{
+				Condition: fmt.Sprintf("%s %s", keyword, archCondition(a.Arch)),
+				URL:       a.URL,
+				SHA256:    a.SHA256,
+			})
+		}
+		blocks = append(blocks, platformBlock{OS: homebrewOS(os), Archs: archs})
+	}
+	return blocks
+}
+
+// homebrewOS maps a GOOS-style artifact OS to the `on_` block Homebrew's DSL
+// actually defines: `on_macos`, not `on_darwin`.
+func homebrewOS(os string) string {
+	if os == "darwin" {
+		return "macos"
+	}
+	return os
+}
+
+// archCondition maps a GOARCH-style artifact arch (amd64, arm64, arm/v5,
+// arm/v6, arm/v7) to the Hardware::CPU check Homebrew expects. The arm/vN
+// variants all run on 32-bit ARM, which Hardware::CPU can't tell apart, so
+// they're additionally disambiguated by `uname -m`'s ABI suffix (eg
+// "armv7l").
+func archCondition(arch string) string {
+	switch {
+	case arch == "amd64" || arch == "386":
+		return "Hardware::CPU.intel?"
+	case arch == "arm64":
+		return "Hardware::CPU.arm?"
+	case arch == "arm":
+		return "Hardware::CPU.arm? && !Hardware::CPU.is_64_bit?"
+	case strings.HasPrefix(arch, "arm/"):
+		abi := strings.TrimPrefix(arch, "arm/")
+		return fmt.Sprintf("Hardware::CPU.arm? && !Hardware::CPU.is_64_bit? && `uname -m`.strip.include?(%q)", abi)
+	default:
+		return "Hardware::CPU.intel?"
+	}
+}
+
+// artifactsFor resolves the --artifact flags into Artifacts with their SHA256
+// and release URL already computed, falling back to the legacy single
+// darwin/amd64 artifact when none were given.
+func artifactsFor(brew Homebrew, tag, urlTemplate string) ([]Artifact, error) {
+	if len(brew.Artifacts) == 0 {
+		path := "dist/" + brew.Name + "/" + brew.Name + "-darwin-amd64"
+		artifact := Artifact{
+			Name: brew.Name + "-darwin-amd64",
+			OS:   "darwin",
+			Arch: "amd64",
+			Path: path,
+		}
+		if err := resolveArtifact(brew, tag, urlTemplate, &artifact); err != nil {
+			return nil, err
+		}
+		return []Artifact{artifact}, nil
+	}
+
+	artifacts := make([]Artifact, 0, len(brew.Artifacts))
+	for _, spec := range brew.Artifacts {
+		goos, arch, path, err := parseArtifactFlag(spec)
+		if err != nil {
+			return nil, err
+		}
+		artifacts = append(artifacts, Artifact{
+			Name: brew.Name + "-" + goos + "-" + strings.Replace(arch, "/", "", -1),
+			OS:   goos,
+			Arch: arch,
+			Path: path,
+		})
+	}
+	for i := range artifacts {
+		if err := resolveArtifact(brew, tag, urlTemplate, &artifacts[i]); err != nil {
+			return nil, err
+		}
+	}
+	return artifacts, nil
+}
+
+// resolveArtifact fills in an Artifact's Version, Owner, Repo, SHA256 and
+// rendered release URL in place.
+func resolveArtifact(brew Homebrew, tag, urlTemplate string, a *Artifact) error {
+	sum, err := calculate(a.Path)
+	if err != nil {
+		return err
+	}
+	a.Version = tag
+	a.Owner = brew.Owner
+	a.Repo = brew.Repo
+	a.SHA256 = sum
+	a.URL, err = renderReleaseURL(urlTemplate, releaseURLContext{
+		Owner: brew.Owner,
+		Repo:  brew.Repo,
+		Tag:   tag,
+		File:  a.Name,
+	})
+	return err
+}
+
+// parseArtifactFlag splits a "os/arch=path" --artifact value, eg
+// "linux/arm/v7=dist/tool-linux-armv7".
+func parseArtifactFlag(spec string) (goos, arch, path string, err error) {
+	parts := strings.SplitN(spec, "=", 2)
+	if len(parts) != 2 {
+		return "", "", "", fmt.Errorf("invalid --artifact %q, want os/arch=path", spec)
+	}
+	platform, path := parts[0], parts[1]
+
+	platformParts := strings.SplitN(platform, "/", 2)
+	if len(platformParts) != 2 {
+		return "", "", "", fmt.Errorf("invalid --artifact %q, want os/arch=path", spec)
+	}
+	return platformParts[0], platformParts[1], path, nil
+}
+
+// installFor picks the default `install` block: a single hardcoded binary
+// name for a one-artifact formula, or a glob that finds whichever
+// platform-specific archive Homebrew downloaded.
+func installFor(name string, artifacts []Artifact) string {
+	if len(artifacts) == 1 {
+		return fmt.Sprintf("bin.install %s", artifacts[0].Name)
+	}
+	return fmt.Sprintf("bin.install Dir[\"%s-*\"].first => \"%s\"", name, name)
+}
+
+func split(s string) []string {
+	strings := strings.Split(strings.TrimSpace(s), "\n")
+	if len(strings) == 1 && strings[0] == "" {
+		return []string{}
+	}
+	return strings
+}
+
+func calculate(path string) (sum string, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+
+	_, err = io.Copy(hash, f)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func calculateBytes(data []byte) (string, error) {
+	hash := sha256.New()
+	if _, err := hash.Write(data); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+type Artifact struct {
+	Name    string
+	OS      string
+	Arch    string
+	Path    string
+	Version string
+	Owner   string
+	Repo    string
+	SHA256  string
+	URL     string
+}
+
+// releaseURLContext is the data available to a provider's ReleaseURLTemplate.
+type releaseURLContext struct {
+	Owner string
+	Repo  string
+	Tag   string
+	File  string
+}
+
+func renderReleaseURL(tmplText string, data releaseURLContext) (string, error) {
+	tmpl, err := template.New("release-url").Parse(tmplText)
+	if err != nil {
+		return "", err
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+func formulaNameFor(name string) string {
+	name = strings.Replace(name, "-", " ", -1)
+	name = strings.Replace(name, "_", " ", -1)
+	return strings.Replace(strings.Title(name), " ", "", -1)
+}
@@ -0,0 +1,64 @@
+package cmds
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestBuildFormulaMultiArchPerOS guards against the if/elsif/end chain
+// regressing into one if/end pair per arch, which Ruby rejects as soon as an
+// OS has more than one architecture.
+func TestBuildFormulaMultiArchPerOS(t *testing.T) {
+	brew := Homebrew{Name: "tool", Description: "a tool", Homepage: "https://example.com"}
+	artifacts := []Artifact{
+		{Name: "tool-darwin-amd64", OS: "darwin", Arch: "amd64", Version: "v1.0.0", URL: "urlA", SHA256: "shaA"},
+		{Name: "tool-darwin-arm64", OS: "darwin", Arch: "arm64", Version: "v1.0.0", URL: "urlB", SHA256: "shaB"},
+	}
+
+	data, err := dataFor(brew, artifacts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := doBuildFormula(data, formulaTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	formula := out.String()
+
+	want := `  on_macos do
+    if Hardware::CPU.intel?
+      url "urlA"
+      sha256 "shaA"
+    elsif Hardware::CPU.arm?
+      url "urlB"
+      sha256 "shaB"
+    end
+  end`
+	if !strings.Contains(formula, want) {
+		t.Fatalf("formula does not contain a single if/elsif/end chain:\n%s", formula)
+	}
+	if strings.Contains(formula, "on_darwin") {
+		t.Fatalf("formula uses on_darwin, which Homebrew's DSL doesn't define; want on_macos:\n%s", formula)
+	}
+	if strings.Count(formula, "end\n  end") > 1 {
+		t.Fatalf("formula has more than one on_macos block closed, want one:\n%s", formula)
+	}
+}
+
+// TestArchConditionDistinguishesARMVariants guards against arm/v5, arm/v6
+// and arm/v7 collapsing to the same Hardware::CPU condition, which would
+// make whichever one is listed first silently win regardless of hardware.
+func TestArchConditionDistinguishesARMVariants(t *testing.T) {
+	seen := map[string]bool{}
+	for _, arch := range []string{"arm/v5", "arm/v6", "arm/v7"} {
+		cond := archCondition(arch)
+		if seen[cond] {
+			t.Fatalf("archCondition(%q) = %q collides with another arm variant", arch, cond)
+		}
+		seen[cond] = true
+		if !strings.Contains(cond, "Hardware::CPU.arm?") {
+			t.Fatalf("archCondition(%q) = %q, want an arm check", arch, cond)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package cmds
+
+// UploadableType tags a manifest entry with what `publish` (or other release
+// tooling reading the manifest) should do with it.
+type UploadableType string
+
+const (
+	// UploadableBinary is a per-platform archive `build` hashed but did not
+	// upload anywhere; it's expected to already be attached to a release.
+	UploadableBinary UploadableType = "UploadableBinary"
+	// UploadableBrewTap is the rendered formula file `publish` pushes to the
+	// tap repo.
+	UploadableBrewTap UploadableType = "UploadableBrewTap"
+)
+
+// ManifestEntry describes one artifact `build` produced, so `publish` (or
+// downstream tooling) can inspect or sign it without re-hashing.
+type ManifestEntry struct {
+	Type   UploadableType `json:"type"`
+	OS     string         `json:"os,omitempty"`
+	Arch   string         `json:"arch,omitempty"`
+	Path   string         `json:"path"`
+	SHA256 string         `json:"sha256"`
+}
+
+// Manifest is written by `build` to dist/<name>.manifest.json and read by
+// `publish`.
+type Manifest struct {
+	Name    string          `json:"name"`
+	Owner   string          `json:"owner"`
+	Repo    string          `json:"repo"`
+	Tag     string          `json:"tag"`
+	Entries []ManifestEntry `json:"entries"`
+}
@@ -0,0 +1,300 @@
+package cmds
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"code.gitea.io/sdk/gitea"
+	"github.com/google/go-github/github"
+	"github.com/xanzy/go-gitlab"
+	"golang.org/x/oauth2"
+)
+
+// Client is the abstraction over the Git provider hosting the tap repo, so
+// that `publish` isn't hard-wired to GitHub.
+type Client interface {
+	CreateFile(ctx context.Context, owner, repo, branch, path, message string, content []byte, author CommitAuthor) error
+	UpdateFile(ctx context.Context, owner, repo, branch, path, message string, content []byte, author CommitAuthor) error
+}
+
+// CommitAuthor is the commit identity used for CreateFile/UpdateFile,
+// provider-agnostic.
+type CommitAuthor struct {
+	Name  string
+	Email string
+}
+
+// errFileNotFound is returned by UpdateFile so that callers know to fall
+// back to CreateFile instead.
+var errFileNotFound = errors.New("file not found")
+
+// NotImplementedError is returned by NewClient for a --provider brewer
+// doesn't support.
+type NotImplementedError struct {
+	Provider string
+}
+
+func (e NotImplementedError) Error() string {
+	return fmt.Sprintf("%s is not implemented", e.Provider)
+}
+
+// MissingTokenError is returned by NewClient when a supported provider's
+// token env var isn't set, so callers can tell it apart from an unsupported
+// --provider value.
+type MissingTokenError struct {
+	EnvVar string
+}
+
+func (e MissingTokenError) Error() string {
+	return fmt.Sprintf("%s is not set", e.EnvVar)
+}
+
+// NewClient builds the Client for the given --provider, reading its token
+// from the provider's own env var. apiURL overrides the public SaaS API,
+// for self-hosted GitLab/Gitea instances.
+func NewClient(provider, apiURL string) (Client, error) {
+	switch provider {
+	case "", "github":
+		return newGitHubClient(apiURL)
+	case "gitlab":
+		return newGitLabClient(apiURL)
+	case "gitea":
+		return newGiteaClient(apiURL)
+	default:
+		return nil, NotImplementedError{Provider: provider}
+	}
+}
+
+// releaseURLTemplate returns the Go text/template string used to render an
+// artifact's release download URL for the given provider. It's a pure
+// function of (provider, apiURL) so that `build` can resolve it without
+// authenticating a Client it has no other use for.
+func releaseURLTemplate(provider, apiURL string) (string, error) {
+	switch provider {
+	case "", "github":
+		return "https://github.com/{{ .Owner }}/{{ .Repo }}/releases/download/{{ .Tag }}/{{ .File }}", nil
+	case "gitlab":
+		base := "https://gitlab.com"
+		if apiURL != "" {
+			base = strings.TrimSuffix(strings.TrimSuffix(apiURL, "/"), "/api/v4")
+		}
+		return base + "/{{ .Owner }}/{{ .Repo }}/-/releases/{{ .Tag }}/downloads/{{ .File }}", nil
+	case "gitea":
+		if apiURL == "" {
+			apiURL = "https://gitea.com"
+		}
+		return apiURL + "/{{ .Owner }}/{{ .Repo }}/releases/download/{{ .Tag }}/{{ .File }}", nil
+	default:
+		return "", NotImplementedError{Provider: provider}
+	}
+}
+
+// pullRequestClient is implemented by providers that support --pull-request.
+type pullRequestClient interface {
+	createTopicBranch(ctx context.Context, brew Homebrew) (string, error)
+	openPullRequest(ctx context.Context, brew Homebrew, branch, title string) error
+}
+
+type githubClient struct {
+	client *github.Client
+}
+
+func newGitHubClient(apiURL string) (Client, error) {
+	token, found := os.LookupEnv("GH_TOOLS_TOKEN")
+	if !found {
+		return nil, MissingTokenError{EnvVar: "GH_TOOLS_TOKEN"}
+	}
+
+	ctx := context.Background()
+	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
+	tc := oauth2.NewClient(ctx, ts)
+
+	if apiURL == "" {
+		return &githubClient{client: github.NewClient(tc)}, nil
+	}
+	client, err := github.NewEnterpriseClient(apiURL, apiURL, tc)
+	if err != nil {
+		return nil, err
+	}
+	return &githubClient{client: client}, nil
+}
+
+func (c *githubClient) CreateFile(ctx context.Context, owner, repo, branch, path, message string, content []byte, author CommitAuthor) error {
+	_, _, err := c.client.Repositories.CreateFile(ctx, owner, repo, path, &github.RepositoryContentFileOptions{
+		Committer: &github.CommitAuthor{Name: github.String(author.Name), Email: github.String(author.Email)},
+		Content:   content,
+		Message:   github.String(message),
+		Branch:    optionalString(branch),
+	})
+	return err
+}
+
+func (c *githubClient) UpdateFile(ctx context.Context, owner, repo, branch, path, message string, content []byte, author CommitAuthor) error {
+	file, _, res, err := c.client.Repositories.GetContents(ctx, owner, repo, path, &github.RepositoryContentGetOptions{Ref: branch})
+	if err != nil {
+		if res != nil && res.StatusCode == http.StatusNotFound {
+			return errFileNotFound
+		}
+		return err
+	}
+	_, _, err = c.client.Repositories.UpdateFile(ctx, owner, repo, path, &github.RepositoryContentFileOptions{
+		Committer: &github.CommitAuthor{Name: github.String(author.Name), Email: github.String(author.Email)},
+		Content:   content,
+		Message:   github.String(message),
+		SHA:       file.SHA,
+		Branch:    optionalString(branch),
+	})
+	return err
+}
+
+// createTopicBranch creates brew/<name>-<tag> off the tap repo's default
+// branch and returns its name.
+func (c *githubClient) createTopicBranch(ctx context.Context, brew Homebrew) (string, error) {
+	repo, _, err := c.client.Repositories.Get(ctx, brew.BrewOwner, brew.BrewRepo)
+	if err != nil {
+		return "", err
+	}
+	base := repo.GetDefaultBranch()
+
+	baseRef, _, err := c.client.Git.GetRef(ctx, brew.BrewOwner, brew.BrewRepo, "refs/heads/"+base)
+	if err != nil {
+		return "", err
+	}
+
+	branch := fmt.Sprintf("brew/%s-%s", brew.Name, brew.Tag)
+	_, _, err = c.client.Git.CreateRef(ctx, brew.BrewOwner, brew.BrewRepo, &github.Reference{
+		Ref:    github.String("refs/heads/" + branch),
+		Object: baseRef.Object,
+	})
+	if err != nil {
+		return "", err
+	}
+	return branch, nil
+}
+
+func (c *githubClient) openPullRequest(ctx context.Context, brew Homebrew, branch, title string) error {
+	repo, _, err := c.client.Repositories.Get(ctx, brew.BrewOwner, brew.BrewRepo)
+	if err != nil {
+		return err
+	}
+	_, _, err = c.client.PullRequests.Create(ctx, brew.BrewOwner, brew.BrewRepo, &github.NewPullRequest{
+		Title: github.String(title),
+		Head:  github.String(branch),
+		Base:  github.String(repo.GetDefaultBranch()),
+	})
+	return err
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+type gitlabClient struct {
+	client *gitlab.Client
+}
+
+func newGitLabClient(apiURL string) (Client, error) {
+	token, found := os.LookupEnv("GITLAB_TOKEN")
+	if !found {
+		return nil, MissingTokenError{EnvVar: "GITLAB_TOKEN"}
+	}
+
+	var opts []gitlab.ClientOptionFunc
+	if apiURL != "" {
+		opts = append(opts, gitlab.WithBaseURL(apiURL))
+	}
+	client, err := gitlab.NewClient(token, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &gitlabClient{client: client}, nil
+}
+
+func (c *gitlabClient) CreateFile(ctx context.Context, owner, repo, branch, path, message string, content []byte, author CommitAuthor) error {
+	_, _, err := c.client.RepositoryFiles.CreateFile(owner+"/"+repo, path, &gitlab.CreateFileOptions{
+		Branch:        gitlab.String(branch),
+		Content:       gitlab.String(string(content)),
+		CommitMessage: gitlab.String(message),
+		AuthorName:    gitlab.String(author.Name),
+		AuthorEmail:   gitlab.String(author.Email),
+	}, gitlab.WithContext(ctx))
+	return err
+}
+
+func (c *gitlabClient) UpdateFile(ctx context.Context, owner, repo, branch, path, message string, content []byte, author CommitAuthor) error {
+	project := owner + "/" + repo
+	_, res, err := c.client.RepositoryFiles.GetFile(project, path, &gitlab.GetFileOptions{Ref: gitlab.String(branch)}, gitlab.WithContext(ctx))
+	if err != nil {
+		if res != nil && res.StatusCode == http.StatusNotFound {
+			return errFileNotFound
+		}
+		return err
+	}
+	_, _, err = c.client.RepositoryFiles.UpdateFile(project, path, &gitlab.UpdateFileOptions{
+		Branch:        gitlab.String(branch),
+		Content:       gitlab.String(string(content)),
+		CommitMessage: gitlab.String(message),
+		AuthorName:    gitlab.String(author.Name),
+		AuthorEmail:   gitlab.String(author.Email),
+	}, gitlab.WithContext(ctx))
+	return err
+}
+
+type giteaClient struct {
+	client *gitea.Client
+}
+
+func newGiteaClient(apiURL string) (Client, error) {
+	token, found := os.LookupEnv("GITEA_TOKEN")
+	if !found {
+		return nil, MissingTokenError{EnvVar: "GITEA_TOKEN"}
+	}
+	if apiURL == "" {
+		apiURL = "https://gitea.com"
+	}
+	client, err := gitea.NewClient(apiURL, gitea.SetToken(token))
+	if err != nil {
+		return nil, err
+	}
+	return &giteaClient{client: client}, nil
+}
+
+func (c *giteaClient) CreateFile(ctx context.Context, owner, repo, branch, path, message string, content []byte, author CommitAuthor) error {
+	_, _, err := c.client.CreateFile(owner, repo, path, gitea.CreateFileOptions{
+		FileOptions: gitea.FileOptions{
+			BranchName: branch,
+			Message:    message,
+			Author:     gitea.Identity{Name: author.Name, Email: author.Email},
+		},
+		Content: base64.StdEncoding.EncodeToString(content),
+	})
+	return err
+}
+
+func (c *giteaClient) UpdateFile(ctx context.Context, owner, repo, branch, path, message string, content []byte, author CommitAuthor) error {
+	file, res, err := c.client.GetContents(owner, repo, branch, path)
+	if err != nil {
+		if res != nil && res.StatusCode == http.StatusNotFound {
+			return errFileNotFound
+		}
+		return err
+	}
+	_, _, err = c.client.UpdateFile(owner, repo, path, gitea.UpdateFileOptions{
+		FileOptions: gitea.FileOptions{
+			BranchName: branch,
+			Message:    message,
+			Author:     gitea.Identity{Name: author.Name, Email: author.Email},
+		},
+		SHA:     file.SHA,
+		Content: base64.StdEncoding.EncodeToString(content),
+	})
+	return err
+}
@@ -0,0 +1,175 @@
+package cmds
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"text/template"
+
+	"github.com/spf13/cobra"
+)
+
+func NewCmdPublish() *cobra.Command {
+	var brew Homebrew
+	var manifestPath string
+
+	cmd := &cobra.Command{
+		Use:   "publish",
+		Short: "Publish a formula built by `build` to a homebrew tap",
+
+		Run: func(cmd *cobra.Command, args []string) {
+			runPublish(manifestPath, brew)
+		},
+	}
+
+	cmd.Flags().StringVar(&manifestPath, "manifest", "", "Path to the manifest written by `build` (required).")
+	cmd.Flags().StringVar(&brew.BrewOwner, "brew-owner", "appscode", "Owner of the reporitory to push the tap to")
+	cmd.Flags().StringVar(&brew.BrewRepo, "brew-repo", "homebrew-tap", "Reporitory to push the tap to")
+	cmd.Flags().StringVar(&brew.Author, "author", "1gtm", "Author name")
+	cmd.Flags().StringVar(&brew.AuthorEmail, "email", "1gtm@appscode.com", "Author email")
+	cmd.Flags().StringVar(&brew.CommitMessageTemplate, "commit-message-template", "Brew formula update for {{ .ProjectName }} version {{ .Tag }}", "Go text/template for the commit message and PR title. Fields: ProjectName, Tag, Version, Owner, Repo, Env, Date.")
+	cmd.Flags().BoolVar(&brew.PullRequest, "pull-request", false, "Push the formula to a topic branch and open a PR instead of committing straight to the tap's default branch.")
+	cmd.Flags().StringVar(&brew.Provider, "provider", "github", "Git provider hosting the tap repo: github, gitlab, or gitea.")
+	cmd.Flags().StringVar(&brew.APIURL, "api-url", "", "Base API URL for a self-hosted GitLab/Gitea instance. Defaults to the provider's public SaaS API.")
+
+	return cmd
+}
+
+// runPublish reads the manifest `build` wrote, pulls out its
+// UploadableBrewTap entry, and pushes that formula to the tap repo.
+func runPublish(manifestPath string, brew Homebrew) {
+	if manifestPath == "" {
+		log.Fatal("--manifest is required")
+	}
+
+	raw, err := ioutil.ReadFile(manifestPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		log.Fatal(err)
+	}
+
+	var tap *ManifestEntry
+	for i, e := range manifest.Entries {
+		if e.Type == UploadableBrewTap {
+			tap = &manifest.Entries[i]
+			break
+		}
+	}
+	if tap == nil {
+		log.Fatalf("manifest %s has no %s entry", manifestPath, UploadableBrewTap)
+	}
+
+	content, err := ioutil.ReadFile(tap.Path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if sum, err := calculateBytes(content); err != nil {
+		log.Fatal(err)
+	} else if sum != tap.SHA256 {
+		log.Fatalf("%s has been modified since build wrote the manifest", tap.Path)
+	}
+
+	brew.Name = manifest.Name
+	brew.Owner = manifest.Owner
+	brew.Repo = manifest.Repo
+	brew.Tag = manifest.Tag
+
+	client, err := NewClient(brew.Provider, brew.APIURL)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	message, err := renderCommitMessage(brew.CommitMessageTemplate, commitMessageContext{
+		ProjectName: brew.Name,
+		Tag:         brew.Tag,
+		Version:     brew.Tag,
+		Owner:       brew.Owner,
+		Repo:        brew.Repo,
+		Env:         envMap(),
+		Date:        time.Now(),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := upload(client, brew, *bytes.NewBuffer(content), brew.Name+".rb", message); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// commitMessageContext is the data available to --commit-message-template.
+type commitMessageContext struct {
+	ProjectName string
+	Tag         string
+	Version     string
+	Owner       string
+	Repo        string
+	Env         map[string]string
+	Date        time.Time
+}
+
+func renderCommitMessage(tmplText string, data commitMessageContext) (string, error) {
+	tmpl, err := template.New("commit-message").Parse(tmplText)
+	if err != nil {
+		return "", err
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+func envMap() map[string]string {
+	env := map[string]string{}
+	for _, kv := range os.Environ() {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) == 2 {
+			env[parts[0]] = parts[1]
+		}
+	}
+	return env
+}
+
+// upload publishes the rendered formula through the given provider Client,
+// falling back from an update to a create when the file doesn't exist yet.
+func upload(client Client, brew Homebrew, content bytes.Buffer, path, message string) error {
+	ctx := context.Background()
+	author := CommitAuthor{Name: brew.Author, Email: brew.AuthorEmail}
+
+	var branch string
+	if brew.PullRequest {
+		pr, ok := client.(pullRequestClient)
+		if !ok {
+			return fmt.Errorf("--pull-request is not supported with --provider %s", brew.Provider)
+		}
+		var err error
+		branch, err = pr.createTopicBranch(ctx, brew)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := client.UpdateFile(ctx, brew.BrewOwner, brew.BrewRepo, branch, path, message, content.Bytes(), author)
+	if err == errFileNotFound {
+		err = client.CreateFile(ctx, brew.BrewOwner, brew.BrewRepo, branch, path, message, content.Bytes(), author)
+	}
+	if err != nil {
+		return err
+	}
+
+	if branch == "" {
+		return nil
+	}
+	return client.(pullRequestClient).openPullRequest(ctx, brew, branch, message)
+}
@@ -0,0 +1,116 @@
+package cmds
+
+const formulaTemplate = `{{- range .CustomBlock }}
+{{ . }}
+{{- end }}
+class {{ .Name }} < Formula
+  desc "{{ .Desc }}"
+  homepage "{{ .Homepage }}"
+  version "{{ .Version }}"
+{{- range .Dependencies }}
+  depends_on "{{ . }}"
+{{- end }}
+{{- range .Conflicts }}
+  conflicts_with "{{ . }}"
+{{- end }}
+{{- if .Platforms }}
+{{- range .Platforms }}
+  on_{{ .OS }} do
+  {{- range .Archs }}
+    {{ .Condition }}
+      url "{{ .URL }}"{{ if $.DownloadStrategy }}, using: {{ $.DownloadStrategy }}{{ end }}
+      sha256 "{{ .SHA256 }}"
+  {{- end }}
+    end
+  end
+{{- end }}
+{{- else }}
+  url "{{ .DownloadURL }}"{{ if .DownloadStrategy }}, using: {{ .DownloadStrategy }}{{ end }}
+  sha256 "{{ .SHA256 }}"
+{{- end }}
+
+  def install
+  {{- range .Install }}
+    {{ . }}
+  {{- end }}
+  end
+{{- if .PostInstall }}
+
+  def post_install
+  {{- range .PostInstall }}
+    {{ . }}
+  {{- end }}
+  end
+{{- end }}
+{{- if .Service }}
+
+  service do
+  {{- range .Service }}
+    {{ . }}
+  {{- end }}
+  end
+{{- end }}
+{{- if .Plist }}
+
+  def plist; <<~EOS
+{{ .Plist }}
+  EOS
+  end
+{{- end }}
+{{- if .Test }}
+
+  test do
+  {{- range .Test }}
+    {{ . }}
+  {{- end }}
+  end
+{{- end }}
+{{- if .Caveats }}
+
+  def caveats; <<~EOS
+  {{- range .Caveats }}
+    {{ . }}
+  {{- end }}
+  EOS
+  end
+{{- end }}
+end
+`
+
+// templateData is the context handed to formulaTemplate.
+type templateData struct {
+	Name             string
+	DownloadURL      string
+	DownloadStrategy string
+	Desc             string
+	Homepage         string
+	Owner            string
+	Repo             string
+	Tag              string
+	Version          string
+	Caveats          []string
+	File             string
+	SHA256           string
+	Dependencies     []string
+	Conflicts        []string
+	Plist            string
+	Install          []string
+	Platforms        []platformBlock
+	CustomBlock      []string
+	Test             []string
+	PostInstall      []string
+	Service          []string
+}
+
+// platformBlock renders a single `on_macos`/`on_linux` block in formulaTemplate.
+type platformBlock struct {
+	OS    string
+	Archs []archBlock
+}
+
+// archBlock renders one `url`/`sha256` pair guarded by a Hardware::CPU condition.
+type archBlock struct {
+	Condition string
+	URL       string
+	SHA256    string
+}